@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Checkpoint is periodically written to -checkpoint so an interrupted
+// FDNS scan can resume with -resume instead of starting over.
+type Checkpoint struct {
+	// Offset is the byte offset into the underlying file (compressed,
+	// if gzipped) at which the checkpoint was taken.
+	Offset int64 `json:"offset"`
+	Count  int64 `json:"count"`
+	Valid  int64 `json:"valid"`
+}
+
+// loadCheckpoint reads a Checkpoint previously written by saveCheckpoint.
+func loadCheckpoint(path string) (Checkpoint, error) {
+	var cp Checkpoint
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cp, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("parsing checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// saveCheckpoint atomically writes cp to path.
+func saveCheckpoint(path string, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
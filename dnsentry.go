@@ -0,0 +1,11 @@
+package main
+
+// DNSEntry is a single FDNS record: a DNS Name resolving to Value
+// (an IP for A/AAAA, a hostname for CNAME, ...) of the given record
+// Type, as found in a Rapid7 FDNS dump line or returned by a passive
+// source.
+type DNSEntry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
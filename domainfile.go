@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// parseDomainFile reads a -domains/-bdomains file, one entry per line.
+// A line parsing as a CIDR (e.g. "10.0.0.0/8") is collected into ips;
+// anything else is treated as a 2nd-level domain. Blank lines and "#"
+// comments are ignored.
+func parseDomainFile(path string) ([]string, []*net.IPNet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening domain file: %w", err)
+	}
+	defer f.Close()
+
+	var domains []string
+	var ips []*net.IPNet
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if _, ipnet, err := net.ParseCIDR(line); err == nil {
+			ips = append(ips, ipnet)
+			continue
+		}
+
+		domains = append(domains, strings.ToLower(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading domain file: %w", err)
+	}
+
+	return domains, ips, nil
+}
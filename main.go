@@ -1,23 +1,41 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net"
-	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/cqr-cryeye-forks/redAsset/matcher"
+	"github.com/cqr-cryeye-forks/redAsset/passivesource"
+	"github.com/cqr-cryeye-forks/redAsset/resolver"
 )
 
-var jsonOutput *json.Encoder
-var count = 1
-var valid = 0
-var tt = time.Now()
+// totalValid is the number of records accepted across both the FDNS
+// pipeline's workers and the passive-source goroutine.
+var totalValid int64
+
+var resolverPool *resolver.Pool
+var onlyLive bool
+
+var output Writer
+
+var explain bool
+
+// upstreamList collects repeated -upstream flags into a []string.
+type upstreamList []string
+
+func (u *upstreamList) String() string     { return strings.Join(*u, ",") }
+func (u *upstreamList) Set(v string) error { *u = append(*u, v); return nil }
 
 func main() {
 
@@ -25,12 +43,70 @@ func main() {
 	parseDomainFilter := flag.String("domains", "", "File containing 2nd level domains to include.")
 	parseDomainBlacklist := flag.String("bdomains", "", "File containing 2nd level domains to exclude.")
 	useCATrans := flag.Bool("catransoff", false, "Deactivate querying certificate transparency logs (crt.sh).")
+	sourceConfig := flag.String("config", "", "Passive source config file (YAML or JSON) enabling additional discovery backends.")
+
+	doResolve := flag.Bool("resolve", false, "Re-resolve each candidate name against -upstream and enrich it with live A/AAAA/CNAME data.")
+	var upstreams upstreamList
+	flag.Var(&upstreams, "upstream", "Resolver upstream, repeatable (udp://, tcp://, tls:// or https://). Defaults to udp://8.8.8.8:53.")
+	resolveWorkers := flag.Int("resolve-workers", 8, "Concurrent emit/resolve workers: how many matched records are resolved and written at once.")
+	flag.BoolVar(&onlyLive, "only-live", false, "Drop records that do not resolve to a live A/AAAA/CNAME. Implies -resolve.")
+
+	outputFormat := flag.String("output-format", "text", "Output format: text, jsonl, json or csv.")
+	outputFile := flag.String("output", "", "File to write results to, gzip-compressed if it ends in .gz. Defaults to stdout.")
+
+	flag.BoolVar(&explain, "explain", false, "Log which rule accepted or rejected each candidate name.")
+
+	fdnsWorkers := flag.Int("workers", 4, "Concurrent workers filtering FDNS records.")
+	checkpointPath := flag.String("checkpoint", "", "File to periodically record FDNS scan progress to, for -resume.")
+	resumeScan := flag.Bool("resume", false, "Resume an FDNS scan from -checkpoint instead of starting from the beginning of -file.")
+
+	metricsAddr := flag.String("metrics-addr", "", "Address to serve Prometheus metrics and /healthz on, e.g. :9090. Disabled if empty.")
+	enablePprof := flag.Bool("pprof", false, "Mount net/http/pprof under /debug/pprof/ on -metrics-addr.")
 
 	flag.Parse()
 
+	if *metricsAddr != "" {
+		go serveMetrics(*metricsAddr, *enablePprof)
+	}
+
+	if onlyLive {
+		*doResolve = true
+	}
+
+	var outW io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := OpenOutput(*outputFile)
+		if err != nil {
+			log.Fatalf("Error opening -output file: %v", err)
+		}
+		defer f.Close()
+		outW = f
+	}
+
+	var err error
+	output, err = NewWriter(*outputFormat, outW)
+	if err != nil {
+		log.Fatalf("Error setting up -output-format: %v", err)
+	}
+	defer output.Close()
+	handleShutdownSignals()
+
+	if *doResolve {
+		if len(upstreams) == 0 {
+			upstreams = upstreamList{"udp://8.8.8.8:53"}
+		}
+		ups, err := resolver.ParseUpstreams(upstreams, nil, 5*time.Second, 1)
+		if err != nil {
+			log.Fatalf("Error parsing -upstream: %v", err)
+		}
+		resolverPool = resolver.NewPool(ups, *resolveWorkers)
+	}
+
 	var allowedDomains []string
 	var blacklistDomains []string
 	var ips []*net.IPNet
+	var allowSet *matcher.Set
+	var blacklistSet *matcher.Set
 	var wg sync.WaitGroup
 
 	if *parseFile == "" {
@@ -46,6 +122,11 @@ func main() {
 			log.Fatalf("Error reading domain file: %v", err)
 		}
 
+		allowSet, err = matcher.Compile(append(allowedDomains, cidrStrings(ips)...))
+		if err != nil {
+			log.Fatalf("Error compiling allow rules: %v", err)
+		}
+
 		log.Printf("Limiting to %v 2nd-lvl domains.", len(allowedDomains))
 	}
 
@@ -56,21 +137,38 @@ func main() {
 			log.Fatalf("Error reading blacklist domain file: %v", err)
 		}
 
+		blacklistSet, err = matcher.Compile(append(blacklistDomains, cidrStrings(ips)...))
+		if err != nil {
+			log.Fatalf("Error compiling blacklist rules: %v", err)
+		}
+
 		log.Printf("Limiting to %v blacklisted 2nd-lvl domains.", len(blacklistDomains))
 	}
 
 	wg.Add(1)
 	go func() {
 		log.Println("Parsing FDNS file.")
-		parseFDNS(*parseFile, allowedDomains, blacklistDomains, ips)
+		parseFDNSPipeline(*parseFile, allowSet, blacklistSet, *fdnsWorkers, *resolveWorkers, *checkpointPath, *resumeScan)
 		wg.Done()
 	}()
 
-	if !*useCATrans {
+	var sources []passivesource.Source
+	if *sourceConfig != "" {
+		cfg, err := passivesource.LoadConfig(*sourceConfig)
+		if err != nil {
+			log.Fatalf("Error reading passive source config: %v", err)
+		}
+		sources = passivesource.Build(cfg, crtshObserver{})
+		log.Printf("Loaded %v passive source(s) from config.", len(sources))
+	} else if !*useCATrans {
+		sources = []passivesource.Source{passivesource.NewCrtSh(15*time.Second, crtshObserver{})}
+	}
+
+	if len(sources) > 0 {
 		wg.Add(1)
 		go func() {
-			log.Println("Querying certificate transparency logs.")
-			queryCATransparency(allowedDomains, blacklistDomains)
+			log.Println("Querying passive sources.")
+			queryPassiveSources(sources, allowedDomains, allowSet, blacklistSet, *resolveWorkers)
 			wg.Done()
 		}()
 	}
@@ -80,101 +178,165 @@ func main() {
 	log.Println("Finished.")
 }
 
-func queryCATransparency(allowed []string, blacklist []string) {
-	var bodyDomain []struct {
-		Domain string `json:"name_value"`
-	}
+// handleShutdownSignals flushes output on SIGINT/SIGTERM before exiting,
+// since an interrupted run otherwise skips output's deferred Close and
+// loses anything still buffered by -output-format text/csv/json (see
+// output.go's Writer.Flush).
+func handleShutdownSignals() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
-	for _, domain := range allowed {
-		url := fmt.Sprintf("https://crt.sh/?q=%%%v&output=json", domain)
-		resp, err := http.Get(url)
-		if err != nil {
-			log.Printf("Error contacting crt.sh: %s", err)
-			continue
+	go func() {
+		sig := <-sigCh
+		log.Printf("Received %v, flushing output before exit.", sig)
+		if err := output.Close(); err != nil {
+			log.Printf("Error flushing output on shutdown: %v", err)
 		}
-		defer resp.Body.Close()
+		os.Exit(1)
+	}()
+}
 
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			continue
-		}
+func queryPassiveSources(sources []passivesource.Source, domains []string, allowSet, blacklistSet *matcher.Set, emitWorkers int) {
+	ctx := context.Background()
 
-		json.Unmarshal([]byte(body), &bodyDomain)
+	jobs, emitWg := startEmitWorkers(emitWorkers)
+	defer func() {
+		close(jobs)
+		emitWg.Wait()
+	}()
 
-		for _, d := range bodyDomain {
-			if isValidResult(DNSEntry{Name: d.Domain}, allowed, blacklist, []*net.IPNet{}) {
-				fmt.Println(d.Domain)
-				valid++
+	for _, domain := range domains {
+		for _, source := range sources {
+			results, err := source.Fetch(ctx, domain)
+			if err != nil {
+				log.Printf("Error querying %v: %s", source.Name(), err)
+				continue
+			}
+
+			var got int
+			for entry := range results {
+				got++
+				dnsentry := DNSEntry{Name: entry.Name, Type: entry.Type, Value: entry.Value}
+				if ok, rule := isValidResult(dnsentry, allowSet, blacklistSet); ok {
+					metrics.recordsMatched.WithLabelValues(entry.Source).Inc()
+					jobs <- emitJob{entry: dnsentry, source: entry.Source, rule: rule}
+				}
 			}
-		}
 
-		log.Printf("CA transparency: Got %v certificates for '%v'", len(bodyDomain), domain)
+			log.Printf("%v: Got %v results for '%v'", source.Name(), got, domain)
+		}
 	}
 }
 
-func parseFDNS(fname string, allowed []string, blacklist []string, ips []*net.IPNet) {
+// emitJob is a single matched record queued for emit by an emit worker.
+type emitJob struct {
+	entry  DNSEntry
+	source string
+	rule   string
+}
+
+// startEmitWorkers starts n goroutines calling emit for every emitJob
+// sent on the returned channel, so emit's -resolve round-trip (the
+// slowest step in the pipeline) doesn't serialize the FDNS writer or
+// the passive-source loop behind a single in-flight resolve. Actual
+// resolve concurrency is still bounded by resolverPool's own semaphore,
+// not by n; callers close the returned channel and Wait on the
+// *sync.WaitGroup once they're done sending.
+func startEmitWorkers(n int) (chan<- emitJob, *sync.WaitGroup) {
+	if n <= 0 {
+		n = 1
+	}
 
-	if len(allowed) <= 0 && len(ips) <= 0 {
-		log.Fatal("No valid domains (0) and IPs (0) parsed.")
+	jobs := make(chan emitJob, n*4)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				emit(j.entry, j.source, j.rule)
+			}
+		}()
 	}
+	return jobs, &wg
+}
 
-	for dnsentry := range parseDnsHosts(fname) {
+// emit writes a filtered DNS entry through the configured output
+// Writer, optionally re-resolving it first and, with -only-live,
+// dropping it if it does not resolve or is no longer live.
+func emit(dnsentry DNSEntry, source string, matchedRule string) {
+	record := Record{
+		Name:        dnsentry.Name,
+		Source:      source,
+		Value:       dnsentry.Value,
+		MatchedRule: matchedRule,
+		Timestamp:   time.Now(),
+	}
 
-		if count%1000000 == 0 && count > 0 {
-			log.Printf("FDNS: %vm processed, %v valid (took %v)", count/1000000, valid, time.Since(tt))
-			tt = time.Now()
+	if resolverPool != nil {
+		start := time.Now()
+		res, err := resolverPool.ResolveOne(context.Background(), dnsentry.Name)
+		timeSince(metrics.resolveLatency, start)
+		if onlyLive && (err != nil || !res.Live) {
+			return
 		}
-
-		if isValidResult(dnsentry, allowed, blacklist, ips) {
-			fmt.Println(dnsentry.Name)
-			valid++
+		if len(res.A) > 0 {
+			record.Value = res.A[0]
+			record.Type = "A"
+		} else if len(res.AAAA) > 0 {
+			record.Value = res.AAAA[0]
+			record.Type = "AAAA"
+		} else if res.CNAME != "" {
+			record.Value = res.CNAME
+			record.Type = "CNAME"
 		}
-
-		count++
 	}
 
+	if err := output.Write(record); err != nil {
+		log.Printf("Error writing output record for %v: %v", dnsentry.Name, err)
+		return
+	}
+	atomic.AddInt64(&totalValid, 1)
 }
 
-func isValidResult(dnsentry DNSEntry, allowed []string, blacklist []string, ips []*net.IPNet) bool {
-
-	//check if IP is in one of the parsed networks
-	if len(ips) > 0 {
-		entryIp := net.ParseIP(dnsentry.Value)
-		for _, ip := range ips {
-			if ip.Contains(entryIp) {
-				return true
+// isValidResult applies the matcher rule engine to dnsentry: a
+// blacklist match rejects regardless of allow rules, otherwise an entry
+// is accepted if allowSet is empty or matches the entry's name or IP.
+// The precedence itself lives in matcher.Set.Match/matcher.Explain, so
+// -explain logs exactly the decision this function acted on.
+func isValidResult(dnsentry DNSEntry, allowSet, blacklistSet *matcher.Set) (bool, string) {
+	ip := net.ParseIP(dnsentry.Value)
+
+	if blacklistSet.Len() > 0 {
+		if ok, _ := blacklistSet.Match(dnsentry.Name, ip); ok {
+			if explain {
+				log.Printf("%v", matcher.Explain(dnsentry.Name, ip, allowSet, blacklistSet))
 			}
-		}
-
-		// if no allowed domains are passed, stop here
-		if len(allowed) <= 0 {
-			return false
+			return false, ""
 		}
 	}
 
-	// check if allowed domain
-	if len(allowed) > 0 {
-		if !isAllowed(allowed, dnsentry.Name) {
-			return false
+	if allowSet.Len() > 0 {
+		ok, rule := allowSet.Match(dnsentry.Name, ip)
+		if explain {
+			log.Printf("%v", matcher.Explain(dnsentry.Name, ip, allowSet, blacklistSet))
 		}
-	}
-
-	// remove blacklisted domains
-	if len(blacklist) > 0 {
-		if isAllowed(blacklist, dnsentry.Name) {
-			return false
+		if !ok {
+			return false, ""
 		}
+		return true, rule.Raw
 	}
 
-	return true
+	return true, ""
 }
 
-func isAllowed(allowed []string, domain string) bool {
-
-	for _, s := range allowed {
-		if strings.HasSuffix(domain, s) {
-			return true
-		}
+// cidrStrings renders ips back to CIDR strings so they can be folded
+// into the same rule list as plain domains when compiling a
+// matcher.Set.
+func cidrStrings(ips []*net.IPNet) []string {
+	var out []string
+	for _, ip := range ips {
+		out = append(out, ip.String())
 	}
-	return false
+	return out
 }
@@ -0,0 +1,44 @@
+package matcher
+
+import (
+	"fmt"
+	"net"
+)
+
+// Decision records why a single name was accepted or rejected, for
+// -explain mode.
+type Decision struct {
+	Name    string
+	Allowed bool
+	Rule    *Rule // nil if no rule matched
+}
+
+func (d Decision) String() string {
+	verb := "accepted"
+	if !d.Allowed {
+		verb = "rejected"
+	}
+	if d.Rule == nil {
+		return fmt.Sprintf("%s: %s (no matching rule)", d.Name, verb)
+	}
+	return fmt.Sprintf("%s: %s by rule %q", d.Name, verb, d.Rule.Raw)
+}
+
+// Explain evaluates name (and ip, if a candidate has one) against allow
+// and blacklist following the engine's precedence (blacklist beats
+// allow, most-specific match wins within each Set) and returns the
+// Decision that isValidResult would have produced.
+func Explain(name string, ip net.IP, allow, blacklist *Set) Decision {
+	if blacklist.Len() > 0 {
+		if ok, rule := blacklist.Match(name, ip); ok {
+			return Decision{Name: name, Allowed: false, Rule: rule}
+		}
+	}
+
+	if allow.Len() > 0 {
+		ok, rule := allow.Match(name, ip)
+		return Decision{Name: name, Allowed: ok, Rule: rule}
+	}
+
+	return Decision{Name: name, Allowed: true}
+}
@@ -0,0 +1,226 @@
+// Package matcher implements the allow/blacklist rule engine, modelled
+// on the X.509 name-constraints semantics: a plain domain constrains its
+// own DNS-label subtree (".example.com" also matches "example.com"
+// itself, but never "evilexample.com"), alongside explicit wildcards,
+// regexes and IP ranges so domains and IPs can be mixed in one rules
+// file.
+package matcher
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Kind identifies how a Rule is evaluated.
+type Kind int
+
+const (
+	// KindSuffix is a plain domain constraining its DNS-label subtree,
+	// e.g. "example.com" matches "example.com" and "www.example.com"
+	// but not "evilexample.com".
+	KindSuffix Kind = iota
+	// KindWildcard is a single-level "*.sub.example.com" constraint.
+	KindWildcard
+	// KindRegex is a "~<pattern>" constraint matched against the full
+	// name.
+	KindRegex
+	// KindEmail is an "@example.com" constraint for SAN email
+	// addresses.
+	KindEmail
+	// KindURI identifies SAN URI constraints. Compile produces no
+	// KindURI rules, and MatchName and MatchIP do not evaluate them.
+	KindURI
+	// KindCIDR is an IPv4/IPv6 range.
+	KindCIDR
+)
+
+// Rule is a single compiled constraint.
+type Rule struct {
+	Raw  string
+	Kind Kind
+
+	suffix string         // KindSuffix, KindEmail, KindURI: ".example.com"
+	re     *regexp.Regexp // KindRegex, KindWildcard
+	cidr   *net.IPNet     // KindCIDR
+
+	// labels is the number of DNS labels in suffix, used to pick the
+	// most-specific match among several that apply.
+	labels int
+}
+
+// Set is an ordered collection of compiled Rules.
+type Set struct {
+	rules []*Rule
+}
+
+// Compile parses raw rule lines (as read from a -domains/-bdomains
+// file) into a Set. Supported syntaxes per line:
+//
+//	example.com              DNS-label-boundary suffix match
+//	*.corp.example.com       single-level wildcard
+//	~^api-\d+\.example\.com$ regex, matched against the full name
+//	@example.com             email address domain (crt.sh SAN emails)
+//	10.0.0.0/8, ::1/128      IPv4/IPv6 CIDR
+func Compile(raw []string) (*Set, error) {
+	s := &Set{}
+
+	for _, line := range raw {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := compileOne(line)
+		if err != nil {
+			return nil, fmt.Errorf("compiling rule %q: %w", line, err)
+		}
+		s.rules = append(s.rules, rule)
+	}
+
+	return s, nil
+}
+
+func compileOne(line string) (*Rule, error) {
+	switch {
+	case strings.HasPrefix(line, "~"):
+		re, err := regexp.Compile(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &Rule{Raw: line, Kind: KindRegex, re: re}, nil
+
+	case strings.HasPrefix(line, "@"):
+		domain := strings.ToLower(strings.TrimPrefix(line, "@"))
+		return &Rule{Raw: line, Kind: KindEmail, suffix: normalizeSuffix(domain), labels: labelCount(domain)}, nil
+
+	case strings.Contains(line, "/") && looksLikeCIDR(line):
+		_, ipnet, err := net.ParseCIDR(line)
+		if err != nil {
+			return nil, err
+		}
+		return &Rule{Raw: line, Kind: KindCIDR, cidr: ipnet}, nil
+
+	case strings.HasPrefix(line, "*."):
+		pattern := "^[^.]+\\." + regexp.QuoteMeta(strings.TrimPrefix(line, "*.")) + "$"
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &Rule{Raw: line, Kind: KindWildcard, re: re, labels: labelCount(line)}, nil
+
+	default:
+		domain := strings.ToLower(line)
+		return &Rule{Raw: line, Kind: KindSuffix, suffix: normalizeSuffix(domain), labels: labelCount(domain)}, nil
+	}
+}
+
+func looksLikeCIDR(s string) bool {
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// normalizeSuffix returns the DNS-label-boundary form of domain, i.e.
+// prefixed with a dot so that HasSuffix(name, suffix) only matches on a
+// label boundary.
+func normalizeSuffix(domain string) string {
+	return "." + strings.TrimPrefix(domain, ".")
+}
+
+func labelCount(domain string) int {
+	return strings.Count(strings.Trim(domain, "."), ".") + 1
+}
+
+// MatchName reports whether name is matched by the Set, returning the
+// most-specific matching Rule (the one with the most DNS labels). Only
+// KindSuffix, KindWildcard, KindRegex and KindEmail rules are
+// considered.
+func (s *Set) MatchName(name string) (bool, *Rule) {
+	name = strings.ToLower(name)
+	dotName := "." + strings.TrimPrefix(name, ".")
+
+	var best *Rule
+	for _, rule := range s.rules {
+		var matched bool
+
+		switch rule.Kind {
+		case KindSuffix:
+			matched = strings.HasSuffix(dotName, rule.suffix) || name == strings.TrimPrefix(rule.suffix, ".")
+		case KindEmail:
+			at := strings.LastIndex(name, "@")
+			if at < 0 {
+				continue
+			}
+			matched = strings.HasSuffix("."+name[at+1:], rule.suffix)
+		case KindWildcard, KindRegex:
+			matched = rule.re.MatchString(name)
+		default:
+			continue
+		}
+
+		if matched && (best == nil || rule.labels > best.labels) {
+			best = rule
+		}
+	}
+
+	return best != nil, best
+}
+
+// MatchIP reports whether ip is contained in one of the Set's KindCIDR
+// rules, returning the first matching Rule.
+func (s *Set) MatchIP(ip net.IP) (bool, *Rule) {
+	if ip == nil {
+		return false, nil
+	}
+	for _, rule := range s.rules {
+		if rule.Kind == KindCIDR && rule.cidr.Contains(ip) {
+			return true, rule
+		}
+	}
+	return false, nil
+}
+
+// Match matches name against the Set, falling back to ip (if non-nil)
+// so that IP CIDR rules and domain rules can live in the same Set. name
+// is checked first, mirroring the precedence a caller with both a name
+// and an IP for the same candidate expects.
+func (s *Set) Match(name string, ip net.IP) (bool, *Rule) {
+	if ok, rule := s.MatchName(name); ok {
+		return true, rule
+	}
+	if ip != nil {
+		if ok, rule := s.MatchIP(ip); ok {
+			return true, rule
+		}
+	}
+	return false, nil
+}
+
+// HasIPRules reports whether the Set has any KindCIDR rules.
+func (s *Set) HasIPRules() bool {
+	for _, rule := range s.rules {
+		if rule.Kind == KindCIDR {
+			return true
+		}
+	}
+	return false
+}
+
+// HasNameRules reports whether the Set has any non-CIDR rules.
+func (s *Set) HasNameRules() bool {
+	for _, rule := range s.rules {
+		if rule.Kind != KindCIDR {
+			return true
+		}
+	}
+	return false
+}
+
+// Len reports the number of compiled rules.
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.rules)
+}
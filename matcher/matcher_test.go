@@ -0,0 +1,123 @@
+package matcher
+
+import (
+	"net"
+	"testing"
+)
+
+func mustCompile(t *testing.T, lines ...string) *Set {
+	t.Helper()
+	s, err := Compile(lines)
+	if err != nil {
+		t.Fatalf("Compile(%v): %v", lines, err)
+	}
+	return s
+}
+
+func TestMatchNameSuffixBoundary(t *testing.T) {
+	set := mustCompile(t, "example.com")
+
+	tests := []struct {
+		name  string
+		match bool
+	}{
+		{"example.com", true},
+		{"www.example.com", true},
+		{"deep.www.example.com", true},
+		{"evilexample.com", false},
+		{"example.com.evil.com", false},
+		{"notexample.com", false},
+	}
+
+	for _, tt := range tests {
+		ok, _ := set.MatchName(tt.name)
+		if ok != tt.match {
+			t.Errorf("MatchName(%q) = %v, want %v", tt.name, ok, tt.match)
+		}
+	}
+}
+
+func TestMatchNameWildcardSingleLevel(t *testing.T) {
+	set := mustCompile(t, "*.corp.example.com")
+
+	tests := []struct {
+		name  string
+		match bool
+	}{
+		{"vpn.corp.example.com", true},
+		{"corp.example.com", false},
+		{"a.b.corp.example.com", false},
+	}
+
+	for _, tt := range tests {
+		ok, _ := set.MatchName(tt.name)
+		if ok != tt.match {
+			t.Errorf("MatchName(%q) = %v, want %v", tt.name, ok, tt.match)
+		}
+	}
+}
+
+func TestMatchNameMostSpecificWins(t *testing.T) {
+	set := mustCompile(t, "example.com", "www.example.com")
+
+	ok, rule := set.MatchName("www.example.com")
+	if !ok {
+		t.Fatal("expected a match")
+	}
+	if rule.Raw != "www.example.com" {
+		t.Errorf("expected most-specific rule %q, got %q", "www.example.com", rule.Raw)
+	}
+}
+
+func TestMatchIP(t *testing.T) {
+	set := mustCompile(t, "10.0.0.0/8")
+
+	ok, _ := set.MatchIP(net.ParseIP("10.1.2.3"))
+	if !ok {
+		t.Error("expected 10.1.2.3 to match 10.0.0.0/8")
+	}
+
+	ok, _ = set.MatchIP(net.ParseIP("192.168.1.1"))
+	if ok {
+		t.Error("did not expect 192.168.1.1 to match 10.0.0.0/8")
+	}
+}
+
+func TestExplainBlacklistBeatsAllow(t *testing.T) {
+	allow := mustCompile(t, "example.com")
+	blacklist := mustCompile(t, "bad.example.com")
+
+	d := Explain("bad.example.com", nil, allow, blacklist)
+	if d.Allowed {
+		t.Errorf("expected bad.example.com to be rejected, got %v", d)
+	}
+	if d.Rule == nil || d.Rule.Raw != "bad.example.com" {
+		t.Errorf("expected rejection by the blacklist rule, got %v", d)
+	}
+}
+
+func TestExplainMatchesIP(t *testing.T) {
+	allow := mustCompile(t, "10.0.0.0/8")
+	blacklist := mustCompile(t)
+
+	d := Explain("unused.example.com", net.ParseIP("10.1.2.3"), allow, blacklist)
+	if !d.Allowed {
+		t.Errorf("expected IP match to be allowed, got %v", d)
+	}
+	if d.Rule == nil || d.Rule.Raw != "10.0.0.0/8" {
+		t.Errorf("expected match by the CIDR rule, got %v", d)
+	}
+}
+
+func TestExplainNoMatch(t *testing.T) {
+	allow := mustCompile(t, "example.com")
+	blacklist := mustCompile(t)
+
+	d := Explain("other.com", nil, allow, blacklist)
+	if d.Allowed {
+		t.Errorf("expected other.com to be rejected, got %v", d)
+	}
+	if d.Rule != nil {
+		t.Errorf("expected no matching rule, got %v", d.Rule)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds the Prometheus collectors exposed on -metrics-addr. The
+// FDNS workers still log a progress line every million records as well.
+var metrics = struct {
+	recordsProcessed prometheus.Counter
+	recordsMatched   *prometheus.CounterVec
+	crtshLatency     prometheus.Histogram
+	crtshErrors      prometheus.Counter
+	resolveLatency   prometheus.Histogram
+}{
+	recordsProcessed: promauto.NewCounter(prometheus.CounterOpts{
+		Name: "redasset_records_processed_total",
+		Help: "Total FDNS records processed.",
+	}),
+	recordsMatched: promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "redasset_records_matched_total",
+		Help: "Total records matched, by source.",
+	}, []string{"source"}),
+	crtshLatency: promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "redasset_crtsh_request_duration_seconds",
+		Help:    "crt.sh request latency.",
+		Buckets: prometheus.DefBuckets,
+	}),
+	crtshErrors: promauto.NewCounter(prometheus.CounterOpts{
+		Name: "redasset_crtsh_errors_total",
+		Help: "Total crt.sh request errors.",
+	}),
+	resolveLatency: promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "redasset_resolve_duration_seconds",
+		Help:    "Resolver upstream lookup latency.",
+		Buckets: prometheus.DefBuckets,
+	}),
+}
+
+// serveMetrics starts the embedded metrics HTTP server on addr,
+// exposing /metrics, /healthz, and (if pprofEnabled) the net/http/pprof
+// profiling endpoints under /debug/pprof/.
+func serveMetrics(addr string, pprofEnabled bool) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	if pprofEnabled {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Error serving metrics: %v", err)
+	}
+}
+
+// timeSince is a small helper for observing a Prometheus histogram with
+// the duration since start.
+func timeSince(h prometheus.Histogram, start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// crtshObserver feeds passivesource.CrtSh's request telemetry into the
+// crtshLatency/crtshErrors metrics.
+type crtshObserver struct{}
+
+func (crtshObserver) ObserveLatency(d time.Duration) { metrics.crtshLatency.Observe(d.Seconds()) }
+func (crtshObserver) IncError()                      { metrics.crtshErrors.Inc() }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a single emitted result, carrying enough provenance for
+// downstream tooling to dedupe and audit matches across runs.
+type Record struct {
+	Name        string    `json:"name"`
+	Source      string    `json:"source"`
+	Type        string    `json:"type,omitempty"`
+	Value       string    `json:"value,omitempty"`
+	MatchedRule string    `json:"matched_rule,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Writer emits Records in a specific output format.
+type Writer interface {
+	Write(Record) error
+	// Flush persists whatever has been buffered so far without closing
+	// the Writer, so a periodic tick or a signal handler can make
+	// progress durable ahead of an interrupted run. For jsonWriter,
+	// which only emits a single JSON array on Close, Flush is a no-op:
+	// use -output-format jsonl for crash-resilient long scans instead.
+	Flush() error
+	Close() error
+}
+
+// NewWriter returns a Writer for the given format ("text", "jsonl",
+// "json" or "csv") writing to w. The returned Writer is safe for
+// concurrent use: the FDNS pipeline and the passive-source goroutine
+// both emit records into it at the same time.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	var inner Writer
+
+	switch format {
+	case "", "text":
+		inner = &textWriter{w: bufio.NewWriter(w)}
+	case "jsonl":
+		inner = &jsonlWriter{enc: json.NewEncoder(w)}
+	case "json":
+		inner = &jsonWriter{w: w}
+	case "csv":
+		inner = &csvWriter{w: csv.NewWriter(w)}
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+
+	return &syncWriter{inner: inner}, nil
+}
+
+// syncWriter serializes Write/Close calls to an underlying Writer with
+// a mutex, since none of textWriter/jsonlWriter/jsonWriter/csvWriter
+// are safe for concurrent use on their own.
+type syncWriter struct {
+	mu    sync.Mutex
+	inner Writer
+}
+
+func (s *syncWriter) Write(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inner.Write(r)
+}
+
+func (s *syncWriter) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inner.Flush()
+}
+
+func (s *syncWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inner.Close()
+}
+
+// OpenOutput opens path for writing, gzip-compressing on the fly if its
+// extension is ".gz" - matching the gzip auto-detection already done for
+// -file on the input side.
+func OpenOutput(path string) (io.WriteCloser, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(path, ".gz") {
+		return gzipWriteCloser{gz: gzip.NewWriter(f), f: f}, nil
+	}
+	return f, nil
+}
+
+type gzipWriteCloser struct {
+	gz *gzip.Writer
+	f  *os.File
+}
+
+func (g gzipWriteCloser) Write(p []byte) (int, error) { return g.gz.Write(p) }
+
+func (g gzipWriteCloser) Close() error {
+	if err := g.gz.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+	return g.f.Close()
+}
+
+// textWriter writes just the name, one per line, matching the tool's
+// original fmt.Println(dnsentry.Name) behaviour.
+type textWriter struct {
+	w *bufio.Writer
+}
+
+func (t *textWriter) Write(r Record) error {
+	_, err := fmt.Fprintln(t.w, r.Name)
+	return err
+}
+
+func (t *textWriter) Flush() error { return t.w.Flush() }
+func (t *textWriter) Close() error { return t.w.Flush() }
+
+// jsonlWriter writes one JSON object per line.
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(r Record) error { return j.enc.Encode(r) }
+func (j *jsonlWriter) Flush() error         { return nil }
+func (j *jsonlWriter) Close() error         { return nil }
+
+// jsonWriter buffers every record and writes a single JSON array on
+// Close. It can't be flushed incrementally without writing invalid
+// partial JSON, so -resume can't recover records written by a jsonWriter
+// that never reached a clean Close; use -output-format jsonl instead
+// for runs where crash-resilience matters.
+type jsonWriter struct {
+	w       io.Writer
+	records []Record
+}
+
+func (j *jsonWriter) Write(r Record) error {
+	j.records = append(j.records, r)
+	return nil
+}
+
+func (j *jsonWriter) Flush() error { return nil }
+
+func (j *jsonWriter) Close() error {
+	return json.NewEncoder(j.w).Encode(j.records)
+}
+
+// csvWriter writes one row per record, with a header row up front.
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+var csvHeader = []string{"name", "source", "type", "value", "matched_rule", "timestamp"}
+
+func (c *csvWriter) Write(r Record) error {
+	if !c.wroteHeader {
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+
+	return c.w.Write([]string{
+		r.Name,
+		r.Source,
+		r.Type,
+		r.Value,
+		r.MatchedRule,
+		r.Timestamp.Format(time.RFC3339),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
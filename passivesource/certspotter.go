@@ -0,0 +1,72 @@
+package passivesource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// CertSpotter queries SSLMate's CertSpotter certificate-transparency
+// search API. An API key raises the request's rate limit but is not
+// required for basic searches.
+type CertSpotter struct {
+	apiKey string
+	client *http.Client
+}
+
+// NewCertSpotter returns a Source backed by CertSpotter.
+func NewCertSpotter(apiKey string, timeout time.Duration) *CertSpotter {
+	return &CertSpotter{apiKey: apiKey, client: &http.Client{Timeout: timeout}}
+}
+
+func (c *CertSpotter) Name() string { return "certspotter" }
+
+func (c *CertSpotter) Fetch(ctx context.Context, domain string) (<-chan Entry, error) {
+	out := make(chan Entry)
+
+	url := fmt.Sprintf("https://api.certspotter.com/v1/issuances?domain=%v&include_subdomains=true&expand=dns_names", domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.SetBasicAuth(c.apiKey, "")
+	}
+
+	go func() {
+		defer close(out)
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return
+		}
+
+		var issuances []struct {
+			DNSNames []string `json:"dns_names"`
+		}
+		if err := json.Unmarshal(body, &issuances); err != nil {
+			return
+		}
+
+		for _, issuance := range issuances {
+			for _, name := range issuance.DNSNames {
+				select {
+				case out <- Entry{Name: name, Source: "certspotter"}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package passivesource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// CrtSh queries crt.sh's JSON output, the same endpoint the original
+// queryCATransparency used directly.
+type CrtSh struct {
+	client *http.Client
+	obs    Observer
+}
+
+// NewCrtSh returns a Source backed by crt.sh. obs, if non-nil, is sent
+// request latency and error counts.
+func NewCrtSh(timeout time.Duration, obs Observer) *CrtSh {
+	return &CrtSh{client: &http.Client{Timeout: timeout}, obs: obs}
+}
+
+func (c *CrtSh) Name() string { return "crtsh" }
+
+func (c *CrtSh) Fetch(ctx context.Context, domain string) (<-chan Entry, error) {
+	out := make(chan Entry)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("https://crt.sh/?q=%%%v&output=json", domain), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(out)
+
+		start := time.Now()
+		resp, err := c.client.Do(req)
+		if c.obs != nil {
+			c.obs.ObserveLatency(time.Since(start))
+		}
+		if err != nil {
+			if c.obs != nil {
+				c.obs.IncError()
+			}
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return
+		}
+
+		var certs []struct {
+			Domain string `json:"name_value"`
+		}
+		if err := json.Unmarshal(body, &certs); err != nil {
+			return
+		}
+
+		for _, cert := range certs {
+			select {
+			case out <- Entry{Name: cert.Domain, Source: "crtsh"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
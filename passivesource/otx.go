@@ -0,0 +1,76 @@
+package passivesource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// OTX queries AlienVault OTX's passive DNS API, which records historical
+// A/CNAME resolutions observed for a domain rather than certificate
+// issuance.
+type OTX struct {
+	apiKey string
+	client *http.Client
+}
+
+// NewOTX returns a Source backed by AlienVault OTX passive DNS. apiKey
+// may be empty, in which case requests are made anonymously and are
+// subject to a stricter rate limit.
+func NewOTX(apiKey string, timeout time.Duration) *OTX {
+	return &OTX{apiKey: apiKey, client: &http.Client{Timeout: timeout}}
+}
+
+func (o *OTX) Name() string { return "otx" }
+
+func (o *OTX) Fetch(ctx context.Context, domain string) (<-chan Entry, error) {
+	out := make(chan Entry)
+
+	url := fmt.Sprintf("https://otx.alienvault.com/api/v1/indicators/domain/%v/passive_dns", domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if o.apiKey != "" {
+		req.Header.Set("X-OTX-API-KEY", o.apiKey)
+	}
+
+	go func() {
+		defer close(out)
+
+		resp, err := o.client.Do(req)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return
+		}
+
+		var result struct {
+			PassiveDNS []struct {
+				Hostname   string `json:"hostname"`
+				Address    string `json:"address"`
+				RecordType string `json:"record_type"`
+			} `json:"passive_dns"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return
+		}
+
+		for _, rec := range result.PassiveDNS {
+			select {
+			case out <- Entry{Name: rec.Hostname, Value: rec.Address, Type: rec.RecordType, Source: "otx"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package passivesource
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RapidDNS scrapes RapidDNS.io's subdomain listing, which is backed by
+// its own FDNS-style crawl and complements the Rapid7 dump used for the
+// local -file scan.
+type RapidDNS struct {
+	client *http.Client
+}
+
+// NewRapidDNS returns a Source backed by rapiddns.io.
+func NewRapidDNS(timeout time.Duration) *RapidDNS {
+	return &RapidDNS{client: &http.Client{Timeout: timeout}}
+}
+
+func (r *RapidDNS) Name() string { return "rapiddns" }
+
+func (r *RapidDNS) Fetch(ctx context.Context, domain string) (<-chan Entry, error) {
+	out := make(chan Entry)
+
+	url := fmt.Sprintf("https://rapiddns.io/subdomain/%v?full=1&down=1", domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(out)
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		// RapidDNS returns an HTML table; the subdomain is the first
+		// column of each data row. Scraping this way is brittle by
+		// nature, but rapiddns.io has no JSON API.
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "<td>") || !strings.HasSuffix(line, "</td>") {
+				continue
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "<td>"), "</td>")
+			if name == "" || !strings.Contains(name, domain) {
+				continue
+			}
+
+			select {
+			case out <- Entry{Name: name, Source: "rapiddns"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
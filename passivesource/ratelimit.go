@@ -0,0 +1,33 @@
+package passivesource
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitedSource wraps a Source, throttling Fetch calls to at most
+// one per interval via a token-bucket limiter, so a single slow/strict
+// provider can't be hammered faster than ProviderConfig.RateLimit allows.
+type rateLimitedSource struct {
+	Source
+	limiter *rate.Limiter
+}
+
+// withRateLimit wraps src so that Fetch blocks until the limiter admits
+// another request, if interval is positive. A non-positive interval
+// returns src unchanged.
+func withRateLimit(src Source, interval time.Duration) Source {
+	if interval <= 0 {
+		return src
+	}
+	return &rateLimitedSource{Source: src, limiter: rate.NewLimiter(rate.Every(interval), 1)}
+}
+
+func (s *rateLimitedSource) Fetch(ctx context.Context, domain string) (<-chan Entry, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.Source.Fetch(ctx, domain)
+}
@@ -0,0 +1,91 @@
+// Package passivesource implements a pluggable set of backends for
+// discovering DNS names for a domain outside of the local FDNS dump:
+// certificate-transparency log APIs and third-party passive DNS
+// providers. Each backend implements Source and streams results on a
+// channel so callers can fan results into the same filtering pipeline
+// used for FDNS records.
+package passivesource
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is a single discovery result from a passive source. It mirrors
+// the shape of the FDNS DNSEntry so callers can feed it through the
+// same isValidResult-style filtering without a lossy conversion.
+type Entry struct {
+	Name   string
+	Value  string
+	Type   string
+	Source string
+}
+
+// Observer receives request-level telemetry from backends that support
+// it (currently CrtSh). A nil Observer is valid and simply means no
+// telemetry is recorded.
+type Observer interface {
+	ObserveLatency(time.Duration)
+	IncError()
+}
+
+// Source is implemented by every passive discovery backend.
+type Source interface {
+	// Name returns the short identifier used in config and logging,
+	// e.g. "crtsh" or "otx".
+	Name() string
+	// Fetch queries the backend for the given domain and streams
+	// results on the returned channel. The channel is closed when the
+	// backend has no more results or ctx is cancelled.
+	Fetch(ctx context.Context, domain string) (<-chan Entry, error)
+}
+
+// Config holds the per-provider settings loaded from the -config file.
+type Config struct {
+	Providers []ProviderConfig `json:"providers" yaml:"providers"`
+}
+
+// ProviderConfig enables and tunes a single Source by name.
+type ProviderConfig struct {
+	Name    string `json:"name" yaml:"name"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	APIKey  string `json:"api_key" yaml:"api_key"`
+	// RateLimit, if positive, caps this Source's Fetch calls to one per
+	// interval (see withRateLimit). Zero means unlimited.
+	RateLimit time.Duration `json:"rate_limit" yaml:"rate_limit"`
+	Timeout   time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// Build returns the enabled Sources described by cfg, in the order they
+// appear in the config file. obs receives request telemetry from
+// backends that support it and may be nil.
+func Build(cfg Config, obs Observer) []Source {
+	var sources []Source
+	for _, p := range cfg.Providers {
+		if !p.Enabled {
+			continue
+		}
+
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = 15 * time.Second
+		}
+
+		var source Source
+		switch p.Name {
+		case "crtsh":
+			source = NewCrtSh(timeout, obs)
+		case "certspotter":
+			source = NewCertSpotter(p.APIKey, timeout)
+		case "otx":
+			source = NewOTX(p.APIKey, timeout)
+		case "rapiddns":
+			source = NewRapidDNS(timeout)
+		default:
+			continue
+		}
+
+		sources = append(sources, withRateLimit(source, p.RateLimit))
+	}
+	return sources
+}
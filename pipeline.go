@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cqr-cryeye-forks/redAsset/matcher"
+)
+
+// fdnsStats holds the atomic counters shared by every pipeline worker,
+// replacing the old package-level count/valid/tt globals.
+type fdnsStats struct {
+	count int64
+	valid int64
+}
+
+// countingReader tracks how many bytes have been read from the
+// underlying file, so the pipeline can checkpoint its position in the
+// (possibly gzip-compressed) source file.
+type countingReader struct {
+	r      io.Reader
+	offset int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.offset, int64(n))
+	return n, err
+}
+
+// parseFDNSPipeline replaces the single-threaded parseFDNS with a
+// producer/consumer pipeline: this goroutine decompresses and decodes
+// the FDNS dump, workers concurrent workers run isValidResult, and
+// emitWorkers concurrent emit workers resolve and write matches. A
+// checkpoint is written to checkpointPath (if set) every 30 seconds (see
+// checkpointLoop) so an interrupted run can continue with resume.
+func parseFDNSPipeline(fname string, allowSet, blacklistSet *matcher.Set, workers, emitWorkers int, checkpointPath string, resume bool) {
+
+	if allowSet.Len() <= 0 {
+		log.Fatal("No valid domains (0) and IPs (0) parsed.")
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		log.Fatalf("Error opening FDNS file: %v", err)
+	}
+	defer f.Close()
+
+	stats := &fdnsStats{}
+
+	// gzip streams can only be decoded from the start: there is no way
+	// to seek to an arbitrary mid-stream compressed offset and resume
+	// decompression from there. So -resume always re-reads the file
+	// from byte 0 and skips the records it already saw, rather than
+	// seeking to a checkpointed byte offset.
+	var skipRecords int64
+	if resume && checkpointPath != "" {
+		cp, err := loadCheckpoint(checkpointPath)
+		if err != nil {
+			log.Fatalf("Error loading checkpoint: %v", err)
+		}
+		skipRecords = cp.Count
+		stats.count = cp.Count
+		stats.valid = cp.Valid
+		log.Printf("Resuming from checkpoint: skipping %v already-processed record(s), %v valid so far", cp.Count, cp.Valid)
+	}
+
+	counting := &countingReader{r: f}
+
+	var reader io.Reader = counting
+	if strings.HasSuffix(fname, ".gz") {
+		gz, err := gzip.NewReader(counting)
+		if err != nil {
+			log.Fatalf("Error opening gzip FDNS file: %v", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	if checkpointPath != "" {
+		stop := make(chan struct{})
+		defer close(stop)
+		go checkpointLoop(checkpointPath, counting, stats, stop)
+	}
+
+	raw := make(chan DNSEntry, workers*4)
+	matched := make(chan matchedEntry, workers*4)
+
+	var decodeWg sync.WaitGroup
+	decodeWg.Add(1)
+	go func() {
+		defer decodeWg.Done()
+		decodeFDNS(reader, raw, skipRecords)
+	}()
+
+	var workerWg sync.WaitGroup
+	workerWg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer workerWg.Done()
+			fdnsWorker(raw, matched, allowSet, blacklistSet, stats)
+		}()
+	}
+
+	go func() {
+		decodeWg.Wait()
+		workerWg.Wait()
+		close(matched)
+	}()
+
+	jobs, emitWg := startEmitWorkers(emitWorkers)
+	for m := range matched {
+		jobs <- emitJob{entry: m.entry, source: "fdns", rule: m.rule}
+	}
+	close(jobs)
+	emitWg.Wait()
+}
+
+// decodeFDNS reads newline-delimited FDNS JSON records from r and
+// streams them on out until EOF, discarding the first skip records
+// (used by -resume, see parseFDNSPipeline).
+func decodeFDNS(r io.Reader, out chan<- DNSEntry, skip int64) {
+	defer close(out)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var seen int64
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		seen++
+		if seen <= skip {
+			continue
+		}
+
+		var entry DNSEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		out <- entry
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error reading FDNS file: %v", err)
+	}
+}
+
+type matchedEntry struct {
+	entry DNSEntry
+	rule  string
+}
+
+// fdnsWorker runs isValidResult against every entry from in, forwarding
+// matches to out and periodically logging progress.
+func fdnsWorker(in <-chan DNSEntry, out chan<- matchedEntry, allowSet, blacklistSet *matcher.Set, stats *fdnsStats) {
+	for dnsentry := range in {
+		n := atomic.AddInt64(&stats.count, 1)
+		metrics.recordsProcessed.Inc()
+		if n%1000000 == 0 {
+			log.Printf("FDNS: %vm processed, %v valid", n/1000000, atomic.LoadInt64(&stats.valid))
+		}
+
+		if ok, rule := isValidResult(dnsentry, allowSet, blacklistSet); ok {
+			atomic.AddInt64(&stats.valid, 1)
+			metrics.recordsMatched.WithLabelValues("fdns").Inc()
+			out <- matchedEntry{entry: dnsentry, rule: rule}
+		}
+	}
+}
+
+// checkpointLoop periodically writes the pipeline's progress to path
+// until stop is closed. It also flushes output alongside every
+// checkpoint, since a checkpoint recording Count records processed is
+// only safe to resume from if those records were actually made durable
+// - otherwise -resume's record-count skip (see decodeFDNS) would treat
+// never-written records as already handled.
+func checkpointLoop(path string, counting *countingReader, stats *fdnsStats, stop <-chan struct{}) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			checkpointNow(path, counting, stats)
+		case <-stop:
+			checkpointNow(path, counting, stats)
+			return
+		}
+	}
+}
+
+func checkpointNow(path string, counting *countingReader, stats *fdnsStats) {
+	if err := output.Flush(); err != nil {
+		log.Printf("Error flushing output: %v", err)
+	}
+
+	cp := Checkpoint{
+		Offset: atomic.LoadInt64(&counting.offset),
+		Count:  atomic.LoadInt64(&stats.count),
+		Valid:  atomic.LoadInt64(&stats.valid),
+	}
+	if err := saveCheckpoint(path, cp); err != nil {
+		log.Printf("Error writing checkpoint: %v", err)
+	}
+}
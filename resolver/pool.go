@@ -0,0 +1,42 @@
+package resolver
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Pool re-resolves names against a round-robin of Upstreams, bounding
+// the number of lookups in flight at once to workers. Callers (the emit
+// workers of the FDNS pipeline and of the passive-source goroutine) each
+// call ResolveOne concurrently; the Pool's own concurrency limit bounds
+// lookups independently of how many callers there are.
+type Pool struct {
+	upstreams []*Upstream
+	next      uint32
+	sem       chan struct{}
+}
+
+// NewPool returns a Pool that spreads lookups across upstreams, never
+// running more than workers of them at once.
+func NewPool(upstreams []*Upstream, workers int) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Pool{upstreams: upstreams, sem: make(chan struct{}, workers)}
+}
+
+// ResolveOne re-resolves a single name against the next upstream in
+// round-robin order, blocking until a slot under the Pool's
+// -resolve-workers concurrency limit is free.
+func (p *Pool) ResolveOne(ctx context.Context, name string) (Result, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return Result{Name: name}, ctx.Err()
+	}
+	defer func() { <-p.sem }()
+
+	idx := atomic.AddUint32(&p.next, 1) - 1
+	upstream := p.upstreams[int(idx)%len(p.upstreams)]
+	return upstream.Lookup(ctx, name)
+}
@@ -0,0 +1,219 @@
+// Package resolver re-resolves candidate DNS names against a set of
+// user-specified upstreams, supporting plain UDP/TCP, DNS-over-TLS and
+// DNS-over-HTTPS. It is used to drop stale FDNS/passive-source records
+// and to attach current A/AAAA/CNAME data to the ones that are still
+// live.
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Result is the outcome of re-resolving a single candidate name.
+type Result struct {
+	Name    string
+	Live    bool
+	A       []string
+	AAAA    []string
+	CNAME   string
+	Err     error
+	Latency time.Duration
+}
+
+// Upstream is a single configured resolver, reached over udp://, tcp://,
+// tls:// (DoT) or https:// (DoH), mirroring AdGuard dnsforward's
+// multi-schema upstream bootstrap.
+type Upstream struct {
+	scheme  string
+	addr    string
+	client  *dns.Client
+	doh     string
+	timeout time.Duration
+	retries int
+}
+
+// NewUpstream parses a raw upstream spec such as "udp://8.8.8.8:53",
+// "tls://1.1.1.1:853" or "https://dns.google/dns-query" and returns a
+// ready-to-use Upstream. For an https:// upstream given by hostname,
+// NewUpstream checks that the host resolves through bootstrap; udp://,
+// tcp:// and tls:// hosts are not looked up here.
+func NewUpstream(raw string, bootstrap *net.Resolver, timeout time.Duration, retries int) (*Upstream, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing upstream %q: %w", raw, err)
+	}
+
+	up := &Upstream{scheme: u.Scheme, timeout: timeout, retries: retries}
+
+	switch u.Scheme {
+	case "udp", "tcp":
+		up.addr = withDefaultPort(u.Host, "53")
+		up.client = &dns.Client{Net: u.Scheme, Timeout: timeout}
+	case "tls":
+		up.addr = withDefaultPort(u.Host, "853")
+		up.client = &dns.Client{Net: "tcp-tls", Timeout: timeout}
+	case "https":
+		up.doh = raw
+		if err := bootstrapHost(u.Hostname(), bootstrap); err != nil {
+			return nil, fmt.Errorf("bootstrapping %q: %w", raw, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported upstream scheme %q", u.Scheme)
+	}
+
+	return up, nil
+}
+
+func withDefaultPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// bootstrapHost checks that a hostname-based upstream resolves through
+// the caller-supplied bootstrap resolver (net.DefaultResolver if nil).
+// The resolved addresses are not kept: later lookups dial the hostname
+// through the system resolver.
+func bootstrapHost(host string, bootstrap *net.Resolver) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if bootstrap == nil {
+		bootstrap = net.DefaultResolver
+	}
+	_, err := bootstrap.LookupHost(context.Background(), host)
+	return err
+}
+
+// Lookup resolves name against this upstream, retrying up to u.retries
+// times on transport error.
+func (u *Upstream) Lookup(ctx context.Context, name string) (Result, error) {
+	var lastErr error
+	for attempt := 0; attempt <= u.retries; attempt++ {
+		res, err := u.lookupOnce(ctx, name)
+		if err == nil {
+			return res, nil
+		}
+		lastErr = err
+	}
+	return Result{Name: name}, lastErr
+}
+
+func (u *Upstream) lookupOnce(ctx context.Context, name string) (Result, error) {
+	start := time.Now()
+
+	if u.doh != "" {
+		res, err := u.lookupDoH(ctx, name)
+		res.Latency = time.Since(start)
+		return res, err
+	}
+
+	result := Result{Name: name}
+
+	var lastErr error
+	var successes int
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeCNAME} {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), qtype)
+
+		resp, _, err := u.client.ExchangeContext(ctx, msg, u.addr)
+		if err != nil {
+			// A single query type failing (AAAA is commonly filtered)
+			// shouldn't discard answers already collected from the
+			// others - keep going and only fail if none succeed.
+			lastErr = err
+			continue
+		}
+		successes++
+
+		for _, rr := range resp.Answer {
+			switch rec := rr.(type) {
+			case *dns.A:
+				result.A = append(result.A, rec.A.String())
+			case *dns.AAAA:
+				result.AAAA = append(result.AAAA, rec.AAAA.String())
+			case *dns.CNAME:
+				result.CNAME = rec.Target
+			}
+		}
+	}
+
+	result.Latency = time.Since(start)
+	if successes == 0 {
+		return result, lastErr
+	}
+
+	result.Live = len(result.A) > 0 || len(result.AAAA) > 0 || result.CNAME != ""
+	return result, nil
+}
+
+// lookupDoH performs the same A/AAAA/CNAME lookups as lookupOnce but
+// over DNS-over-HTTPS using RFC 8484 wire format.
+func (u *Upstream) lookupDoH(ctx context.Context, name string) (Result, error) {
+	result := Result{Name: name}
+
+	var lastErr error
+	var successes int
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeCNAME} {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), qtype)
+		msg.Id = 0
+
+		packed, err := msg.Pack()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		resp, err := doHExchange(ctx, u.doh, packed, u.timeout)
+		if err != nil {
+			// As in lookupOnce, one qtype failing (AAAA is commonly
+			// filtered) shouldn't discard answers already collected
+			// from the others - keep going and only fail if none
+			// succeed.
+			lastErr = err
+			continue
+		}
+		successes++
+
+		for _, rr := range resp.Answer {
+			switch rec := rr.(type) {
+			case *dns.A:
+				result.A = append(result.A, rec.A.String())
+			case *dns.AAAA:
+				result.AAAA = append(result.AAAA, rec.AAAA.String())
+			case *dns.CNAME:
+				result.CNAME = rec.Target
+			}
+		}
+	}
+
+	if successes == 0 {
+		return result, lastErr
+	}
+
+	result.Live = len(result.A) > 0 || len(result.AAAA) > 0 || result.CNAME != ""
+	return result, nil
+}
+
+// ParseUpstreams builds an Upstream for every raw spec, failing on the
+// first invalid one.
+func ParseUpstreams(raw []string, bootstrap *net.Resolver, timeout time.Duration, retries int) ([]*Upstream, error) {
+	var ups []*Upstream
+	for _, r := range raw {
+		u, err := NewUpstream(strings.TrimSpace(r), bootstrap, timeout, retries)
+		if err != nil {
+			return nil, err
+		}
+		ups = append(ups, u)
+	}
+	return ups, nil
+}